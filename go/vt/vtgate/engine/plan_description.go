@@ -20,8 +20,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"vitess.io/vitess/go/tools/graphviz"
 	"vitess.io/vitess/go/vt/key"
@@ -31,6 +34,94 @@ import (
 
 const inputName = "InputName"
 
+// hotspotRowsP99Threshold and hotspotLatencyP99Threshold control when
+// addToGraph flags a primitive as a hotspot in the GraphViz output.
+const (
+	hotspotRowsP99Threshold    = 10000
+	hotspotLatencyP99Threshold = 100 * time.Millisecond
+)
+
+// RowsReceived records, for a single primitive, the number of rows returned
+// on each call it served during a query's execution.
+type RowsReceived []int
+
+// PrimitiveStats holds the execution metrics collected for a primitive across
+// every time it was invoked while serving a query. It is populated by the
+// executor when running in an EXPLAIN ANALYZE-style mode and is otherwise
+// left at its zero value.
+type PrimitiveStats struct {
+	// RowsReceived is the number of rows returned on each call.
+	RowsReceived RowsReceived
+	// BytesReceived is the number of bytes returned on each call.
+	BytesReceived []int64
+	// Latencies is the wall-clock time taken by each call.
+	Latencies []time.Duration
+	// ShardFanOut is the total number of shards this primitive fanned out to
+	// across all of its calls.
+	ShardFanOut int
+}
+
+// NoOfCalls returns how many times the primitive was invoked.
+func (s PrimitiveStats) NoOfCalls() int {
+	return len(s.RowsReceived)
+}
+
+// TotalDuration returns the sum of all recorded latencies for the primitive.
+func (s PrimitiveStats) TotalDuration() time.Duration {
+	var total time.Duration
+	for _, d := range s.Latencies {
+		total += d
+	}
+	return total
+}
+
+// PrimitiveStatsCollector is the collection point the executor writes to
+// while running a query in an EXPLAIN ANALYZE-style mode: it records one
+// RecordCall per primitive invocation, and Snapshot() hands the accumulated
+// PrimitiveStats to PrimitiveToPlanDescription once the query has finished
+// so they can be attached to the resulting plan description.
+type PrimitiveStatsCollector struct {
+	mu    sync.Mutex
+	stats map[Primitive]*PrimitiveStats
+}
+
+// NewPrimitiveStatsCollector returns an empty PrimitiveStatsCollector.
+func NewPrimitiveStatsCollector() *PrimitiveStatsCollector {
+	return &PrimitiveStatsCollector{stats: map[Primitive]*PrimitiveStats{}}
+}
+
+// RecordCall records the outcome of a single call to p: the number of rows
+// and bytes it returned, how long the call took, and how many shards it
+// fanned out to. It is safe to call concurrently, since sibling primitives
+// in a join or concatenate may execute in parallel.
+func (c *PrimitiveStatsCollector) RecordCall(p Primitive, rows, byteCount int, latency time.Duration, shardFanOut int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.stats[p]
+	if !ok {
+		s = &PrimitiveStats{}
+		c.stats[p] = s
+	}
+	s.RowsReceived = append(s.RowsReceived, rows)
+	s.BytesReceived = append(s.BytesReceived, int64(byteCount))
+	s.Latencies = append(s.Latencies, latency)
+	s.ShardFanOut += shardFanOut
+}
+
+// Snapshot returns the stats collected so far, keyed by primitive, in the
+// shape PrimitiveToPlanDescription expects.
+func (c *PrimitiveStatsCollector) Snapshot() map[Primitive]PrimitiveStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[Primitive]PrimitiveStats, len(c.stats))
+	for p, s := range c.stats {
+		out[p] = *s
+	}
+	return out
+}
+
 // PrimitiveDescription is used to create a serializable representation of the Primitive tree
 // Using this structure, all primitives can share json marshalling code, which gives us an uniform output
 type PrimitiveDescription struct {
@@ -48,7 +139,7 @@ type PrimitiveDescription struct {
 	InputName string
 	Inputs    []PrimitiveDescription
 
-	Stats RowsReceived
+	Stats PrimitiveStats
 }
 
 // MarshalJSON serializes the PlanDescription into a JSON representation.
@@ -92,15 +183,63 @@ func (pd PrimitiveDescription) MarshalJSON() ([]byte, error) {
 			return nil, err
 		}
 	}
-	if len(pd.Stats) > 0 {
-		if err := marshalAdd(prepend, buf, "NoOfCalls", len(pd.Stats)); err != nil {
+	if len(pd.Stats.RowsReceived) > 0 {
+		if err := marshalAdd(prepend, buf, "NoOfCalls", pd.Stats.NoOfCalls()); err != nil {
 			return nil, err
 		}
 
-		if err := marshalAdd(prepend, buf, "AvgNumberOfRows", average(pd.Stats)); err != nil {
+		if err := marshalAdd(prepend, buf, "AvgNumberOfRows", average(pd.Stats.RowsReceived)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "MedianNumberOfRows", median(pd.Stats.RowsReceived)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "RowsP50", percentileInt(pd.Stats.RowsReceived, 50)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "RowsP95", percentileInt(pd.Stats.RowsReceived, 95)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "RowsP99", percentileInt(pd.Stats.RowsReceived, 99)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "RowsMax", maxInt(pd.Stats.RowsReceived)); err != nil {
+			return nil, err
+		}
+	}
+	if len(pd.Stats.Latencies) > 0 {
+		if err := marshalAdd(prepend, buf, "LatencyP50", percentileDuration(pd.Stats.Latencies, 50).String()); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "LatencyP95", percentileDuration(pd.Stats.Latencies, 95).String()); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "LatencyP99", percentileDuration(pd.Stats.Latencies, 99).String()); err != nil {
 			return nil, err
 		}
-		if err := marshalAdd(prepend, buf, "MedianNumberOfRows", median(pd.Stats)); err != nil {
+		if err := marshalAdd(prepend, buf, "LatencyMax", maxDuration(pd.Stats.Latencies).String()); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "TotalTime", pd.Stats.TotalDuration().String()); err != nil {
+			return nil, err
+		}
+	}
+	if len(pd.Stats.BytesReceived) > 0 {
+		if err := marshalAdd(prepend, buf, "BytesP50", percentileInt64(pd.Stats.BytesReceived, 50)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "BytesP95", percentileInt64(pd.Stats.BytesReceived, 95)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "BytesP99", percentileInt64(pd.Stats.BytesReceived, 99)); err != nil {
+			return nil, err
+		}
+		if err := marshalAdd(prepend, buf, "BytesMax", maxInt64(pd.Stats.BytesReceived)); err != nil {
+			return nil, err
+		}
+	}
+	if pd.Stats.ShardFanOut > 0 {
+		if err := marshalAdd(prepend, buf, "ShardFanOut", pd.Stats.ShardFanOut); err != nil {
 			return nil, err
 		}
 	}
@@ -142,6 +281,76 @@ func median(nums []int) float64 {
 	return float64(sortedNums[n/2])
 }
 
+// percentileInt returns the p-th percentile (0-100) of nums using the
+// nearest-rank method.
+func percentileInt(nums []int, p int) int {
+	sortedNums := make([]int, len(nums))
+	copy(sortedNums, nums)
+	sort.Ints(sortedNums)
+	return sortedNums[rankFor(len(sortedNums), p)]
+}
+
+// percentileDuration returns the p-th percentile (0-100) of durs using the
+// nearest-rank method.
+func percentileDuration(durs []time.Duration, p int) time.Duration {
+	sortedDurs := make([]time.Duration, len(durs))
+	copy(sortedDurs, durs)
+	sort.Slice(sortedDurs, func(i, j int) bool { return sortedDurs[i] < sortedDurs[j] })
+	return sortedDurs[rankFor(len(sortedDurs), p)]
+}
+
+// rankFor returns the 0-based index of the p-th percentile (0-100) in a
+// sorted slice of length n.
+func rankFor(n int, p int) int {
+	rank := (p*n + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > n {
+		rank = n
+	}
+	return rank - 1
+}
+
+func maxInt(nums []int) int {
+	m := nums[0]
+	for _, num := range nums[1:] {
+		if num > m {
+			m = num
+		}
+	}
+	return m
+}
+
+func maxDuration(durs []time.Duration) time.Duration {
+	m := durs[0]
+	for _, d := range durs[1:] {
+		if d > m {
+			m = d
+		}
+	}
+	return m
+}
+
+// percentileInt64 returns the p-th percentile (0-100) of nums using the
+// nearest-rank method.
+func percentileInt64(nums []int64, p int) int64 {
+	sortedNums := make([]int64, len(nums))
+	copy(sortedNums, nums)
+	sort.Slice(sortedNums, func(i, j int) bool { return sortedNums[i] < sortedNums[j] })
+	return sortedNums[rankFor(len(sortedNums), p)]
+}
+
+func maxInt64(nums []int64) int64 {
+	m := nums[0]
+	for _, num := range nums[1:] {
+		if num > m {
+			m = num
+		}
+	}
+	return m
+}
+
 func (pd PrimitiveDescription) addToGraph(g *graphviz.Graph) (*graphviz.Node, error) {
 	var nodes []*graphviz.Node
 	for _, input := range pd.Inputs {
@@ -149,6 +358,9 @@ func (pd PrimitiveDescription) addToGraph(g *graphviz.Graph) (*graphviz.Node, er
 		if err != nil {
 			return nil, err
 		}
+		if input.InputName != "" {
+			n.AddAttribute(inputName + ":" + input.InputName)
+		}
 		nodes = append(nodes, n)
 	}
 	name := pd.OperatorType + ":" + pd.Variant
@@ -156,6 +368,9 @@ func (pd PrimitiveDescription) addToGraph(g *graphviz.Graph) (*graphviz.Node, er
 		name = pd.OperatorType
 	}
 	this := g.AddNode(name)
+	if pd.isHotspot() {
+		this.AddAttribute("HOTSPOT")
+	}
 	for k, v := range pd.Other {
 		switch k {
 		case "Query":
@@ -180,6 +395,18 @@ func (pd PrimitiveDescription) addToGraph(g *graphviz.Graph) (*graphviz.Node, er
 	return this, nil
 }
 
+// isHotspot reports whether this primitive's p99 rows or latency crossed the
+// thresholds that mark it as a slow branch worth an operator's attention.
+func (pd PrimitiveDescription) isHotspot() bool {
+	if len(pd.Stats.RowsReceived) > 0 && percentileInt(pd.Stats.RowsReceived, 99) > hotspotRowsP99Threshold {
+		return true
+	}
+	if len(pd.Stats.Latencies) > 0 && percentileDuration(pd.Stats.Latencies, 99) > hotspotLatencyP99Threshold {
+		return true
+	}
+	return false
+}
+
 func GraphViz(p Primitive) (*graphviz.Graph, error) {
 	g := graphviz.New()
 	description := PrimitiveToPlanDescription(p, nil)
@@ -190,6 +417,183 @@ func GraphViz(p Primitive) (*graphviz.Graph, error) {
 	return g, nil
 }
 
+// RenderFormat identifies one of the output formats a PlanRenderer can
+// produce from a plan tree.
+type RenderFormat string
+
+const (
+	// RenderFormatGraphViz renders the plan as a GraphViz dot graph.
+	RenderFormatGraphViz RenderFormat = "graphviz"
+	// RenderFormatMermaid renders the plan as a Mermaid flowchart.
+	RenderFormatMermaid RenderFormat = "mermaid"
+	// RenderFormatASCIITree renders the plan as an indented ASCII tree,
+	// suitable for terminal EXPLAIN output.
+	RenderFormatASCIITree RenderFormat = "tree"
+	// RenderFormatOTLP renders the plan as an OpenTelemetry-style span tree,
+	// serialized as nested JSON in the shape described on otlpSpan.
+	RenderFormatOTLP RenderFormat = "otlp"
+)
+
+// PlanRenderer turns a PrimitiveDescription tree into a textual
+// representation written to w. Inputs are rendered in the order they appear
+// in PrimitiveDescription.Inputs, and each input's InputName (e.g.
+// Outer/Subquery) is preserved wherever the format has room to show it.
+type PlanRenderer interface {
+	Render(pd PrimitiveDescription, w io.Writer) error
+}
+
+// Render writes the plan rooted at p to w using the given format.
+func Render(p Primitive, format RenderFormat, w io.Writer) error {
+	renderer, err := rendererFor(format)
+	if err != nil {
+		return err
+	}
+	description := PrimitiveToPlanDescription(p, nil)
+	return renderer.Render(description, w)
+}
+
+func rendererFor(format RenderFormat) (PlanRenderer, error) {
+	switch format {
+	case RenderFormatGraphViz:
+		return graphVizRenderer{}, nil
+	case RenderFormatMermaid:
+		return mermaidRenderer{}, nil
+	case RenderFormatASCIITree:
+		return asciiTreeRenderer{}, nil
+	case RenderFormatOTLP:
+		return otlpRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown plan render format: %s", format)
+	}
+}
+
+// graphVizRenderer renders a plan as a GraphViz dot graph, reusing the same
+// addToGraph traversal that backs the standalone GraphViz function.
+type graphVizRenderer struct{}
+
+func (graphVizRenderer) Render(pd PrimitiveDescription, w io.Writer) error {
+	g := graphviz.New()
+	if _, err := pd.addToGraph(g); err != nil {
+		return err
+	}
+	_, err := fmt.Fprint(w, g.String())
+	return err
+}
+
+// mermaidRenderer renders a plan as a Mermaid flowchart.
+type mermaidRenderer struct{}
+
+func (mermaidRenderer) Render(pd PrimitiveDescription, w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	ids := 0
+	pd.addToMermaid(&sb, &ids)
+	_, err := w.Write([]byte(sb.String()))
+	return err
+}
+
+// addToMermaid writes pd and its inputs as Mermaid flowchart nodes/edges into
+// sb, returning pd's own node id so the caller can draw an edge to it.
+func (pd PrimitiveDescription) addToMermaid(sb *strings.Builder, ids *int) string {
+	*ids++
+	id := fmt.Sprintf("n%d", *ids)
+	label := pd.OperatorType
+	if pd.Variant != "" {
+		label += ":" + pd.Variant
+	}
+	fmt.Fprintf(sb, "    %s[%q]\n", id, label)
+	for _, input := range pd.Inputs {
+		childID := input.addToMermaid(sb, ids)
+		if input.InputName != "" {
+			fmt.Fprintf(sb, "    %s -->|%s| %s\n", id, input.InputName, childID)
+		} else {
+			fmt.Fprintf(sb, "    %s --> %s\n", id, childID)
+		}
+	}
+	return id
+}
+
+// asciiTreeRenderer renders a plan as an indented ASCII tree, suitable for
+// terminal EXPLAIN output.
+type asciiTreeRenderer struct{}
+
+func (asciiTreeRenderer) Render(pd PrimitiveDescription, w io.Writer) error {
+	return pd.writeASCIITree(w, "")
+}
+
+func (pd PrimitiveDescription) writeASCIITree(w io.Writer, prefix string) error {
+	name := pd.OperatorType
+	if pd.Variant != "" {
+		name += ":" + pd.Variant
+	}
+	if pd.InputName != "" {
+		name = pd.InputName + ": " + name
+	}
+	if _, err := fmt.Fprintf(w, "%s%s\n", prefix, name); err != nil {
+		return err
+	}
+	for _, input := range pd.Inputs {
+		if err := input.writeASCIITree(w, prefix+"  "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otlpSpan is a minimal OpenTelemetry-style span used to export a plan tree:
+// each span has a name, a flat attribute map and its child spans nested under
+// "spans". It is not the OTLP/JSON wire format: spans carry no trace or span
+// IDs and no timestamps, and there is no resourceSpans/scopeSpans envelope.
+type otlpSpan struct {
+	Name       string         `json:"name"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Spans      []otlpSpan     `json:"spans,omitempty"`
+}
+
+// otlpRenderer renders a plan as an OpenTelemetry-style span tree.
+type otlpRenderer struct{}
+
+func (otlpRenderer) Render(pd PrimitiveDescription, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pd.toOTLPSpan())
+}
+
+func (pd PrimitiveDescription) toOTLPSpan() otlpSpan {
+	name := pd.OperatorType
+	if pd.Variant != "" {
+		name += ":" + pd.Variant
+	}
+	span := otlpSpan{Name: name}
+
+	attrs := map[string]any{}
+	for k, v := range pd.Other {
+		attrs[k] = v
+	}
+	if pd.InputName != "" {
+		attrs[inputName] = pd.InputName
+	}
+	if pd.Stats.NoOfCalls() > 0 {
+		attrs["stats.noOfCalls"] = pd.Stats.NoOfCalls()
+		attrs["stats.rowsP99"] = percentileInt(pd.Stats.RowsReceived, 99)
+	}
+	if len(pd.Stats.Latencies) > 0 {
+		attrs["stats.latencyP99"] = percentileDuration(pd.Stats.Latencies, 99).String()
+		attrs["stats.totalTime"] = pd.Stats.TotalDuration().String()
+	}
+	if len(pd.Stats.BytesReceived) > 0 {
+		attrs["stats.bytesP99"] = percentileInt64(pd.Stats.BytesReceived, 99)
+	}
+	if len(attrs) > 0 {
+		span.Attributes = attrs
+	}
+
+	for _, input := range pd.Inputs {
+		span.Spans = append(span.Spans, input.toOTLPSpan())
+	}
+	return span
+}
+
 func addMap(input map[string]any, buf *bytes.Buffer) error {
 	var mk []string
 	for k, v := range input {
@@ -219,7 +623,7 @@ func marshalAdd(prepend string, buf *bytes.Buffer, name string, obj any) error {
 
 // PrimitiveToPlanDescription transforms a primitive tree into a corresponding PlanDescription tree
 // If stats is not nil, it will be used to populate the stats field of the PlanDescription
-func PrimitiveToPlanDescription(in Primitive, stats map[Primitive]RowsReceived) PrimitiveDescription {
+func PrimitiveToPlanDescription(in Primitive, stats map[Primitive]PrimitiveStats) PrimitiveDescription {
 	this := in.description()
 	if stats != nil {
 		this.Stats = stats[in]
@@ -315,3 +719,250 @@ func (m orderedMap) String() string {
 	}
 	return strings.Join(output, " ")
 }
+
+// UnmarshalJSON decodes a JSON object into m, preserving the key order found
+// in the input. This is what lets a strict-mode plan's Other map round-trip
+// instead of coming back in whatever order Go happens to range a map.
+func (m *orderedMap) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("orderedMap: expected a JSON object, got %v", tok)
+	}
+
+	var result orderedMap
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return fmt.Errorf("orderedMap: expected a string key, got %v", keyTok)
+		}
+		var val any
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+		result = append(result, keyVal{key: key, val: val})
+	}
+	*m = result
+	return nil
+}
+
+// PlanSchemaVersion is the schema version emitted by StrictPrimitiveDescription.
+// Bump it whenever the shape of that encoding changes in a way that would
+// break golden-file comparisons or cross-version plan diffing.
+const PlanSchemaVersion = 1
+
+// StrictPrimitiveDescription wraps a PrimitiveDescription to opt in to a
+// deterministic, schema-versioned JSON encoding: a PlanSchemaVersion field at
+// the top, stable key ordering at every level (including Other, which is no
+// longer a plain map), no field dropped because its value happens to be the
+// zero value, and TargetDestination encoded as an explicit type tag plus
+// payload instead of a trimmed String(). This is the encoding
+// plan-regression tests and cross-Vitess-version plan diffing should use;
+// PrimitiveDescription's own permissive MarshalJSON is unchanged.
+type StrictPrimitiveDescription struct {
+	PrimitiveDescription
+}
+
+// targetDestinationJSON is the canonical, type-tagged JSON representation of
+// a key.Destination, replacing the ad-hoc s[11:] string slicing the
+// permissive encoding relies on.
+type targetDestinationJSON struct {
+	Type    string `json:"Type"`
+	Payload string `json:"Payload"`
+}
+
+func canonicalTargetDestination(dest key.Destination) *targetDestinationJSON {
+	if dest == nil {
+		return nil
+	}
+	// opaqueDestination already carries the original type tag/payload from a
+	// prior decode; re-deriving them from String()/%T here would mangle them
+	// (its String() embeds the tag), breaking marshal->unmarshal->marshal
+	// stability. Pass them through unchanged instead.
+	if o, ok := dest.(opaqueDestination); ok {
+		return &targetDestinationJSON{Type: o.typeTag, Payload: o.payload}
+	}
+	return &targetDestinationJSON{
+		Type:    fmt.Sprintf("%T", dest),
+		Payload: strings.TrimPrefix(dest.String(), "Destination"),
+	}
+}
+
+// opaqueDestination is the key.Destination StrictPrimitiveDescription's
+// UnmarshalJSON reconstructs from a targetDestinationJSON. It preserves the
+// original type tag and payload for display and diffing, but can't Resolve
+// to real shards: the shard topology that produced the original destination
+// isn't captured in a plan description.
+type opaqueDestination struct {
+	typeTag string
+	payload string
+}
+
+func (d opaqueDestination) String() string {
+	return d.typeTag + "(" + d.payload + ")"
+}
+
+func (d opaqueDestination) Resolve([]*topodatapb.ShardReference, func(shard string) error) error {
+	return fmt.Errorf("%s cannot be resolved: it was reconstructed from a strict-mode plan description, not a live topology", d)
+}
+
+var _ key.Destination = opaqueDestination{}
+
+// strictNodeJSON mirrors PrimitiveDescription field-for-field for the
+// strict encoding. Using a plain struct (rather than the hand-rolled buffer
+// writer MarshalJSON uses) means field order is always the declaration
+// order below, and Other's own MarshalJSON/UnmarshalJSON keep its key order
+// stable too.
+type strictNodeJSON struct {
+	InputName         string                 `json:"InputName"`
+	OperatorType      string                 `json:"OperatorType"`
+	Variant           string                 `json:"Variant"`
+	Keyspace          *vindexes.Keyspace     `json:"Keyspace"`
+	TargetDestination *targetDestinationJSON `json:"TargetDestination"`
+	TargetTabletType  string                 `json:"TargetTabletType"`
+	Other             orderedMap             `json:"Other"`
+	Stats             PrimitiveStats         `json:"Stats"`
+	Inputs            []strictNodeJSON       `json:"Inputs"`
+}
+
+// strictPlanJSON is strictNodeJSON plus the top-level PlanSchemaVersion.
+// Embedding strictNodeJSON flattens its fields alongside PlanSchemaVersion
+// at the same JSON level.
+type strictPlanJSON struct {
+	PlanSchemaVersion int `json:"PlanSchemaVersion"`
+	strictNodeJSON
+}
+
+func (pd PrimitiveDescription) toStrictNode() strictNodeJSON {
+	inputs := make([]strictNodeJSON, len(pd.Inputs))
+	for i, input := range pd.Inputs {
+		inputs[i] = input.toStrictNode()
+	}
+	other := make(orderedMap, 0, len(pd.Other))
+	for k, v := range pd.Other {
+		other = append(other, keyVal{key: k, val: v})
+	}
+	sort.Sort(other)
+	return strictNodeJSON{
+		InputName:         pd.InputName,
+		OperatorType:      pd.OperatorType,
+		Variant:           pd.Variant,
+		Keyspace:          pd.Keyspace,
+		TargetDestination: canonicalTargetDestination(pd.TargetDestination),
+		TargetTabletType:  pd.TargetTabletType.String(),
+		Other:             other,
+		Stats:             pd.Stats,
+		Inputs:            inputs,
+	}
+}
+
+func (n strictNodeJSON) toPrimitiveDescription() PrimitiveDescription {
+	pd := PrimitiveDescription{
+		InputName:    n.InputName,
+		OperatorType: n.OperatorType,
+		Variant:      n.Variant,
+		Keyspace:     n.Keyspace,
+		Stats:        n.Stats,
+	}
+	if n.TargetDestination != nil {
+		pd.TargetDestination = opaqueDestination{
+			typeTag: n.TargetDestination.Type,
+			payload: n.TargetDestination.Payload,
+		}
+	}
+	if tt, ok := topodatapb.TabletType_value[n.TargetTabletType]; ok {
+		pd.TargetTabletType = topodatapb.TabletType(tt)
+	}
+	if len(n.Other) > 0 {
+		pd.Other = make(map[string]any, len(n.Other))
+		for _, kv := range n.Other {
+			pd.Other[kv.key] = kv.val
+		}
+	}
+	for _, input := range n.Inputs {
+		pd.Inputs = append(pd.Inputs, input.toPrimitiveDescription())
+	}
+	if len(n.Inputs) == 0 {
+		pd.Inputs = []PrimitiveDescription{}
+	}
+	return pd
+}
+
+// MarshalJSON serializes spd using the strict, schema-versioned encoding
+// described on StrictPrimitiveDescription.
+func (spd StrictPrimitiveDescription) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strictPlanJSON{
+		PlanSchemaVersion: PlanSchemaVersion,
+		strictNodeJSON:    spd.PrimitiveDescription.toStrictNode(),
+	})
+}
+
+// UnmarshalJSON decodes data, previously produced by MarshalJSON, back into
+// spd so strict-mode plans round-trip for golden-file comparisons.
+func (spd *StrictPrimitiveDescription) UnmarshalJSON(data []byte) error {
+	var raw strictPlanJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	spd.PrimitiveDescription = raw.strictNodeJSON.toPrimitiveDescription()
+	return nil
+}
+
+// Change describes a single structural difference PlanDiff found between two
+// plan trees at the same position.
+type Change struct {
+	// Path identifies where in the tree the change was found, e.g.
+	// "root.Inputs[0]".
+	Path string
+	// Kind is a short machine-readable label, e.g. "operatorType",
+	// "variant", "query", "input-added", "input-removed".
+	Kind string
+	// Before and After hold the differing values. For "input-removed" only
+	// Before is set and for "input-added" only After is set, in each case to
+	// the OperatorType of the input that was removed or added.
+	Before string
+	After  string
+}
+
+// PlanDiff reports the structural differences between two plan trees: added
+// or removed inputs, changed operator variants, and changed queries. It's
+// meant for plan-regression tests and CI jobs asserting a plan didn't
+// unexpectedly change shape across a Vitess version bump.
+func PlanDiff(a, b PrimitiveDescription) []Change {
+	return diffPrimitiveDescriptions("root", a, b)
+}
+
+func diffPrimitiveDescriptions(path string, a, b PrimitiveDescription) []Change {
+	var changes []Change
+	if a.OperatorType != b.OperatorType {
+		changes = append(changes, Change{Path: path, Kind: "operatorType", Before: a.OperatorType, After: b.OperatorType})
+	}
+	if a.Variant != b.Variant {
+		changes = append(changes, Change{Path: path, Kind: "variant", Before: a.Variant, After: b.Variant})
+	}
+	if aq, bq := fmt.Sprintf("%v", a.Other["Query"]), fmt.Sprintf("%v", b.Other["Query"]); aq != bq {
+		changes = append(changes, Change{Path: path, Kind: "query", Before: aq, After: bq})
+	}
+
+	common := len(a.Inputs)
+	if len(b.Inputs) < common {
+		common = len(b.Inputs)
+	}
+	for i := 0; i < common; i++ {
+		changes = append(changes, diffPrimitiveDescriptions(fmt.Sprintf("%s.Inputs[%d]", path, i), a.Inputs[i], b.Inputs[i])...)
+	}
+	for i := common; i < len(a.Inputs); i++ {
+		changes = append(changes, Change{Path: fmt.Sprintf("%s.Inputs[%d]", path, i), Kind: "input-removed", Before: a.Inputs[i].OperatorType})
+	}
+	for i := common; i < len(b.Inputs); i++ {
+		changes = append(changes, Change{Path: fmt.Sprintf("%s.Inputs[%d]", path, i), Kind: "input-added", After: b.Inputs[i].OperatorType})
+	}
+	return changes
+}
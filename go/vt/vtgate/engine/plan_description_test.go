@@ -0,0 +1,351 @@
+/*
+Copyright 2024 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// fakePrimitive is a bare-bones Primitive used only as a distinct,
+// comparable map key in PrimitiveStatsCollector tests.
+type fakePrimitive struct {
+	name string
+}
+
+func (f *fakePrimitive) RouteType() string       { return "Fake" }
+func (f *fakePrimitive) GetKeyspaceName() string { return "" }
+func (f *fakePrimitive) GetTableName() string    { return "" }
+func (f *fakePrimitive) NeedsTransaction() bool  { return false }
+
+func (f *fakePrimitive) GetFields(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	return nil, nil
+}
+
+func (f *fakePrimitive) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	return nil, nil
+}
+
+func (f *fakePrimitive) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	return nil
+}
+
+func (f *fakePrimitive) Inputs() ([]Primitive, []map[string]any) { return nil, nil }
+
+func (f *fakePrimitive) description() PrimitiveDescription {
+	return PrimitiveDescription{OperatorType: f.name}
+}
+
+var _ Primitive = (*fakePrimitive)(nil)
+
+// multiInputPlan returns a small plan tree with two inputs, one of which
+// carries an InputName, for exercising renderer output/ordering.
+func multiInputPlan() PrimitiveDescription {
+	return PrimitiveDescription{
+		OperatorType: "Route",
+		Variant:      "Scatter",
+		Inputs: []PrimitiveDescription{
+			{OperatorType: "ScalarAggregate", InputName: "Outer"},
+			{OperatorType: "Subquery", Variant: "PulloutIn", InputName: "Subquery"},
+		},
+	}
+}
+
+func TestRankFor(t *testing.T) {
+	tests := []struct {
+		name string
+		n, p int
+		want int
+	}{
+		{"n=1 p1", 1, 1, 0},
+		{"n=1 p100", 1, 100, 0},
+		{"n=4 p50", 4, 50, 1},
+		{"n=4 p95", 4, 95, 3},
+		{"n=4 p99", 4, 99, 3},
+		{"n=10 p99", 10, 99, 9},
+		{"n=100 p99", 100, 99, 98},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rankFor(tt.n, tt.p))
+		})
+	}
+}
+
+func TestPercentileInt(t *testing.T) {
+	tests := []struct {
+		name string
+		nums []int
+		p    int
+		want int
+	}{
+		{"single value p50", []int{7}, 50, 7},
+		{"single value p99", []int{7}, 99, 7},
+		{"even length p50", []int{1, 2, 3, 4}, 50, 2},
+		{"even length p95", []int{1, 2, 3, 4}, 95, 4},
+		{"odd length p50", []int{1, 2, 3, 4, 5}, 50, 3},
+		{"unsorted input p100", []int{5, 1, 4, 2, 3}, 100, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentileInt(tt.nums, tt.p))
+		})
+	}
+	assert.Equal(t, 9, maxInt([]int{3, 9, 1}))
+	assert.Equal(t, 5, maxInt([]int{5}))
+}
+
+func TestPercentileDuration(t *testing.T) {
+	durs := []time.Duration{
+		50 * time.Millisecond,
+		10 * time.Millisecond,
+		200 * time.Millisecond,
+		20 * time.Millisecond,
+	}
+	assert.Equal(t, 20*time.Millisecond, percentileDuration(durs, 50))
+	assert.Equal(t, 200*time.Millisecond, percentileDuration(durs, 99))
+	assert.Equal(t, 200*time.Millisecond, maxDuration(durs))
+	assert.Equal(t, time.Millisecond, percentileDuration([]time.Duration{time.Millisecond}, 50))
+}
+
+func TestPercentileInt64(t *testing.T) {
+	nums := []int64{100, 50, 300, 200}
+	assert.Equal(t, int64(100), percentileInt64(nums, 50))
+	assert.Equal(t, int64(300), percentileInt64(nums, 99))
+	assert.Equal(t, int64(300), maxInt64(nums))
+	assert.Equal(t, int64(42), percentileInt64([]int64{42}, 99))
+}
+
+func TestPlanDiff(t *testing.T) {
+	base := PrimitiveDescription{
+		OperatorType: "Route",
+		Variant:      "Scatter",
+		Other:        map[string]any{"Query": "select 1"},
+		Inputs: []PrimitiveDescription{
+			{OperatorType: "ScalarAggregate"},
+		},
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		assert.Empty(t, PlanDiff(base, base))
+	})
+
+	t.Run("variant changed", func(t *testing.T) {
+		other := base
+		other.Variant = "EqualUnique"
+		changes := PlanDiff(base, other)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "variant", changes[0].Kind)
+		assert.Equal(t, "Scatter", changes[0].Before)
+		assert.Equal(t, "EqualUnique", changes[0].After)
+	})
+
+	t.Run("query changed", func(t *testing.T) {
+		other := base
+		other.Other = map[string]any{"Query": "select 2"}
+		changes := PlanDiff(base, other)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "query", changes[0].Kind)
+		assert.Equal(t, "select 1", changes[0].Before)
+		assert.Equal(t, "select 2", changes[0].After)
+	})
+
+	t.Run("input added", func(t *testing.T) {
+		other := base
+		other.Inputs = append(append([]PrimitiveDescription{}, base.Inputs...), PrimitiveDescription{OperatorType: "Filter"})
+		changes := PlanDiff(base, other)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "input-added", changes[0].Kind)
+		assert.Equal(t, "Filter", changes[0].After)
+	})
+
+	t.Run("input removed", func(t *testing.T) {
+		other := base
+		other.Inputs = nil
+		changes := PlanDiff(base, other)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "input-removed", changes[0].Kind)
+		assert.Equal(t, "ScalarAggregate", changes[0].Before)
+	})
+}
+
+func TestStrictPrimitiveDescriptionRoundTrip(t *testing.T) {
+	pd := PrimitiveDescription{
+		OperatorType:     "Route",
+		Variant:          "Scatter",
+		TargetTabletType: topodatapb.TabletType_REPLICA,
+		Other: map[string]any{
+			"Query": "select 1",
+			"Table": "user",
+		},
+		Stats: PrimitiveStats{
+			RowsReceived:  RowsReceived{1, 2, 3},
+			BytesReceived: []int64{10, 20, 30},
+			Latencies:     []time.Duration{time.Millisecond, 2 * time.Millisecond},
+			ShardFanOut:   4,
+		},
+		Inputs: []PrimitiveDescription{
+			{OperatorType: "Filter", InputName: "Outer"},
+		},
+	}
+
+	data, err := json.Marshal(StrictPrimitiveDescription{pd})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"PlanSchemaVersion":1`)
+
+	var got StrictPrimitiveDescription
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, pd.OperatorType, got.OperatorType)
+	assert.Equal(t, pd.Variant, got.Variant)
+	assert.Equal(t, pd.TargetTabletType, got.TargetTabletType)
+	assert.Equal(t, pd.Other, got.Other)
+	assert.Equal(t, pd.Stats, got.Stats)
+	require.Len(t, got.Inputs, 1)
+	assert.Equal(t, "Outer", got.Inputs[0].InputName)
+	assert.Equal(t, "Filter", got.Inputs[0].OperatorType)
+}
+
+func TestStrictPrimitiveDescriptionRoundTripEmptyPlan(t *testing.T) {
+	pd := PrimitiveDescription{OperatorType: "SingleRow"}
+
+	data, err := json.Marshal(StrictPrimitiveDescription{pd})
+	require.NoError(t, err)
+
+	var got StrictPrimitiveDescription
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, "SingleRow", got.OperatorType)
+	assert.Nil(t, got.Other)
+	assert.Nil(t, got.TargetDestination)
+	assert.Empty(t, got.Inputs)
+}
+
+func TestStrictPrimitiveDescriptionTargetDestinationRoundTrip(t *testing.T) {
+	pd := PrimitiveDescription{
+		OperatorType:      "Route",
+		TargetDestination: opaqueDestination{typeTag: "key.DestinationShard", payload: "Shard(-80)"},
+	}
+
+	firstPass, err := json.Marshal(StrictPrimitiveDescription{pd})
+	require.NoError(t, err)
+
+	var got StrictPrimitiveDescription
+	require.NoError(t, json.Unmarshal(firstPass, &got))
+
+	require.NotNil(t, got.TargetDestination)
+	dest, ok := got.TargetDestination.(opaqueDestination)
+	require.True(t, ok)
+	assert.Equal(t, "key.DestinationShard", dest.typeTag)
+	assert.Equal(t, "Shard(-80)", dest.payload)
+	assert.Equal(t, "key.DestinationShard(Shard(-80))", dest.String())
+	assert.ErrorContains(t, dest.Resolve(nil, nil), "cannot be resolved")
+
+	// marshal -> unmarshal -> marshal must be stable: re-encoding the
+	// opaqueDestination decoded above must reproduce the same bytes, not a
+	// mangled type tag derived from opaqueDestination.String().
+	secondPass, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(firstPass), string(secondPass))
+}
+
+func TestMermaidRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, mermaidRenderer{}.Render(multiInputPlan(), &buf))
+
+	want := "flowchart TD\n" +
+		"    n1[\"Route:Scatter\"]\n" +
+		"    n2[\"ScalarAggregate\"]\n" +
+		"    n1 -->|Outer| n2\n" +
+		"    n3[\"Subquery:PulloutIn\"]\n" +
+		"    n1 -->|Subquery| n3\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestASCIITreeRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, asciiTreeRenderer{}.Render(multiInputPlan(), &buf))
+
+	want := "Route:Scatter\n" +
+		"  Outer: ScalarAggregate\n" +
+		"  Subquery: Subquery:PulloutIn\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestOTLPRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, otlpRenderer{}.Render(multiInputPlan(), &buf))
+
+	var got otlpSpan
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+
+	assert.Equal(t, "Route:Scatter", got.Name)
+	assert.Nil(t, got.Attributes)
+	require.Len(t, got.Spans, 2)
+
+	assert.Equal(t, "ScalarAggregate", got.Spans[0].Name)
+	assert.Equal(t, map[string]any{"InputName": "Outer"}, got.Spans[0].Attributes)
+
+	assert.Equal(t, "Subquery:PulloutIn", got.Spans[1].Name)
+	assert.Equal(t, map[string]any{"InputName": "Subquery"}, got.Spans[1].Attributes)
+}
+
+func TestPrimitiveStatsCollector(t *testing.T) {
+	route := &fakePrimitive{name: "Route"}
+	aggregate := &fakePrimitive{name: "ScalarAggregate"}
+
+	c := NewPrimitiveStatsCollector()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+		go func(rows int) {
+			defer wg.Done()
+			c.RecordCall(route, rows, rows*10, time.Duration(rows)*time.Millisecond, 2)
+		}(i + 1)
+		go func(rows int) {
+			defer wg.Done()
+			c.RecordCall(aggregate, rows, rows*10, time.Duration(rows)*time.Millisecond, 0)
+		}(i + 1)
+	}
+	wg.Wait()
+
+	snapshot := c.Snapshot()
+	require.Len(t, snapshot, 2)
+
+	routeStats := snapshot[route]
+	assert.Len(t, routeStats.RowsReceived, 3)
+	assert.Len(t, routeStats.BytesReceived, 3)
+	assert.Len(t, routeStats.Latencies, 3)
+	assert.Equal(t, 6, routeStats.ShardFanOut)
+	assert.ElementsMatch(t, []int{1, 2, 3}, []int(routeStats.RowsReceived))
+
+	aggregateStats := snapshot[aggregate]
+	assert.Len(t, aggregateStats.RowsReceived, 3)
+	assert.Equal(t, 0, aggregateStats.ShardFanOut)
+}